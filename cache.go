@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// packageCacheEntry is what gets written under the cache directory for a
+// single package: the findings FindNonConstCalls produced for callsites in
+// that package, the last time its hash was this value.
+type packageCacheEntry struct {
+	Findings []Finding `json:"findings"`
+}
+
+// resolveCacheDir returns the directory safesql should use for its
+// on-disk cache: dir if explicitly set via -cache-dir, otherwise
+// $XDG_CACHE_HOME/safesql, falling back to $HOME/.cache/safesql.
+func resolveCacheDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "safesql")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "safesql-cache")
+	}
+	return filepath.Join(home, ".cache", "safesql")
+}
+
+// configDigest hashes the driver-config file (if any), so that editing it
+// invalidates every package's cache entry the same way a source edit would.
+func configDigest(configPath string) (string, error) {
+	if configPath == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// packageHash hashes pkg's compiled Go files, its module version (when
+// known), and the run-wide inputs that affect every package's findings the
+// same way a source edit would: the driver-config file, -tags, and -taint.
+func packageHash(pkg *packages.Package, cfgDigest, tags string, taint bool) (string, error) {
+	files := append([]string{}, pkg.CompiledGoFiles...)
+	sort.Strings(files)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "pkg:%s\x00", pkg.PkgPath)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", f)
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	if pkg.Module != nil {
+		fmt.Fprintf(h, "module:%s@%s\x00", pkg.Module.Path, pkg.Module.Version)
+	}
+	fmt.Fprintf(h, "config:%s;tags:%s;taint:%v\x00", cfgDigest, tags, taint)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unattributedPackage is the bucket findings fall into when the SSA
+// function they were found in doesn't resolve back to a package in
+// allPkgs (synthetic wrappers, bound-method thunks, and the like). Its
+// hash is a fingerprint of every other package's hash, so it's only ever a
+// cache hit when nothing else changed either — effectively falling back to
+// whole-run caching for the rare findings a per-package hash can't place.
+const unattributedPackage = ""
+
+// packageHashes computes packageHash for every reachable package, plus the
+// unattributedPackage fingerprint bucket.
+func packageHashes(allPkgs map[string]*packages.Package, cfgDigest, tags string, taint bool) (map[string]string, error) {
+	hashes := make(map[string]string, len(allPkgs)+1)
+	for path, pkg := range allPkgs {
+		hash, err := packageHash(pkg, cfgDigest, tags, taint)
+		if err != nil {
+			return nil, fmt.Errorf("hashing package %s: %w", path, err)
+		}
+		hashes[path] = hash
+	}
+
+	paths := make([]string, 0, len(hashes))
+	for path := range hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fp := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(fp, "%s=%s\n", path, hashes[path])
+	}
+	hashes[unattributedPackage] = hex.EncodeToString(fp.Sum(nil))
+
+	return hashes, nil
+}
+
+func packageCacheFile(dir, hash string) string {
+	return filepath.Join(dir, hash+".json")
+}
+
+func loadPackageFindings(dir, hash string) ([]Finding, bool) {
+	data, err := ioutil.ReadFile(packageCacheFile(dir, hash))
+	if err != nil {
+		return nil, false
+	}
+	var entry packageCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return entry.Findings, true
+}
+
+func storePackageFindings(dir, hash string, findings []Finding) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(packageCacheEntry{Findings: findings})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(packageCacheFile(dir, hash), data, 0o644)
+}
+
+// loadAllCachedFindings collects the cached findings for every package in
+// pkgHashes, provided all of them have a cache entry. This is an all-or-
+// nothing lookup, not partial reuse: go/pointer's callgraph and points-to
+// analysis are inherently whole-program, so there is no way to rebuild SSA
+// and rerun the pointer analysis for only the packages that changed. If
+// even one package is missing a cache entry (first run, or its hash just
+// changed), loadAllCachedFindings returns false and the caller must fall
+// back to a full SSA build + pointer analysis run over the whole program.
+// The per-package hashing still pays for itself on the common CI case of
+// "nothing in this package set changed since the last run".
+func loadAllCachedFindings(dir string, pkgHashes map[string]string) ([]Finding, bool) {
+	var findings []Finding
+	for _, hash := range pkgHashes {
+		pkgFindings, ok := loadPackageFindings(dir, hash)
+		if !ok {
+			return nil, false
+		}
+		findings = append(findings, pkgFindings...)
+	}
+	return findings, true
+}
+
+// storeAllPackageFindings partitions findings by the package they were
+// found in and writes (or refreshes) one cache entry per package in
+// pkgHashes, keyed by that package's own content hash. This lets an
+// unrelated edit elsewhere in the import graph avoid invalidating a given
+// package's entry even though (per loadAllCachedFindings) a full rebuild is
+// still required whenever any package's hash doesn't match.
+func storeAllPackageFindings(dir string, pkgHashes map[string]string, findings []Finding) error {
+	byPackage := make(map[string][]Finding, len(pkgHashes))
+	for path := range pkgHashes {
+		byPackage[path] = nil
+	}
+	for _, f := range findings {
+		byPackage[f.Package] = append(byPackage[f.Package], f)
+	}
+
+	for path, hash := range pkgHashes {
+		if err := storePackageFindings(dir, hash, byPackage[path]); err != nil {
+			return fmt.Errorf("writing cache for package %s: %w", path, err)
+		}
+	}
+	return nil
+}
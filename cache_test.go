@@ -0,0 +1,163 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestConfigDigest(t *testing.T) {
+	if digest, err := configDigest(""); err != nil || digest != "" {
+		t.Fatalf("configDigest(\"\") = (%q, %v), want (\"\", nil)", digest, err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "drivers: []\n")
+
+	d1, err := configDigest(path)
+	if err != nil {
+		t.Fatalf("configDigest: %v", err)
+	}
+	if d1 == "" {
+		t.Fatal("configDigest of a non-empty file returned an empty digest")
+	}
+
+	writeFile(t, path, "drivers: [{package: x}]\n")
+	d2, err := configDigest(path)
+	if err != nil {
+		t.Fatalf("configDigest: %v", err)
+	}
+	if d1 == d2 {
+		t.Fatal("configDigest did not change when the config file's contents changed")
+	}
+}
+
+func TestPackageHashChangesWithSourceConfigTagsAndTaint(t *testing.T) {
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "p.go")
+	writeFile(t, goFile, "package p\n\nfunc F() string { return \"a\" }\n")
+
+	pkg := &packages.Package{PkgPath: "p", CompiledGoFiles: []string{goFile}}
+
+	base, err := packageHash(pkg, "cfg", "tags", false)
+	if err != nil {
+		t.Fatalf("packageHash: %v", err)
+	}
+
+	if h, _ := packageHash(pkg, "cfg", "tags", false); h != base {
+		t.Error("packageHash is not deterministic for identical inputs")
+	}
+	if h, _ := packageHash(pkg, "other-cfg", "tags", false); h == base {
+		t.Error("packageHash did not change when the config digest changed")
+	}
+	if h, _ := packageHash(pkg, "cfg", "other-tags", false); h == base {
+		t.Error("packageHash did not change when -tags changed")
+	}
+	if h, _ := packageHash(pkg, "cfg", "tags", true); h == base {
+		t.Error("packageHash did not change when -taint changed")
+	}
+
+	writeFile(t, goFile, "package p\n\nfunc F() string { return \"b\" }\n")
+	if h, _ := packageHash(pkg, "cfg", "tags", false); h == base {
+		t.Error("packageHash did not change when the source file changed")
+	}
+}
+
+func TestPackageHashReflectsModuleVersion(t *testing.T) {
+	goFile := filepath.Join(t.TempDir(), "p.go")
+	writeFile(t, goFile, "package p\n")
+
+	withoutModule := &packages.Package{PkgPath: "p", CompiledGoFiles: []string{goFile}}
+	withModule := &packages.Package{
+		PkgPath:         "p",
+		CompiledGoFiles: []string{goFile},
+		Module:          &packages.Module{Path: "example.com/p", Version: "v1.0.0"},
+	}
+	bumpedModule := &packages.Package{
+		PkgPath:         "p",
+		CompiledGoFiles: []string{goFile},
+		Module:          &packages.Module{Path: "example.com/p", Version: "v1.1.0"},
+	}
+
+	h0, _ := packageHash(withoutModule, "", "", false)
+	h1, _ := packageHash(withModule, "", "", false)
+	h2, _ := packageHash(bumpedModule, "", "", false)
+
+	if h0 == h1 {
+		t.Error("packageHash did not change when a module became known (nil -> non-nil)")
+	}
+	if h1 == h2 {
+		t.Error("packageHash did not change when the module version was bumped")
+	}
+}
+
+func TestPackageHashesIncludesFingerprintBucket(t *testing.T) {
+	goFile := filepath.Join(t.TempDir(), "p.go")
+	writeFile(t, goFile, "package p\n")
+	pkg := &packages.Package{PkgPath: "p", CompiledGoFiles: []string{goFile}}
+
+	hashes, err := packageHashes(map[string]*packages.Package{"p": pkg}, "cfg", "tags", false)
+	if err != nil {
+		t.Fatalf("packageHashes: %v", err)
+	}
+	if _, ok := hashes[unattributedPackage]; !ok {
+		t.Fatal("packageHashes did not include the unattributedPackage fingerprint bucket")
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("packageHashes returned %d entries, want 2 (one package plus the fingerprint)", len(hashes))
+	}
+}
+
+func TestStoreAndLoadAllCachedFindingsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	pkgHashes := map[string]string{"a": "hash-a", "b": "hash-b"}
+	findings := []Finding{
+		{File: "a.go", Line: 1, Package: "a"},
+		{File: "b.go", Line: 2, Package: "b"},
+		{File: "b.go", Line: 3, Package: "b"},
+	}
+
+	if err := storeAllPackageFindings(dir, pkgHashes, findings); err != nil {
+		t.Fatalf("storeAllPackageFindings: %v", err)
+	}
+
+	got, ok := loadAllCachedFindings(dir, pkgHashes)
+	if !ok {
+		t.Fatal("loadAllCachedFindings reported a miss right after a full store")
+	}
+	// Package is a cache-internal routing key (json:"-"), not part of the
+	// stored entry, so it doesn't round-trip.
+	want := make([]Finding, len(findings))
+	for i, f := range findings {
+		f.Package = ""
+		want[i] = f
+	}
+	sortFindings(got)
+	sortFindings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("loadAllCachedFindings = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadAllCachedFindingsMissOnUnknownHash(t *testing.T) {
+	dir := t.TempDir()
+	if err := storeAllPackageFindings(dir, map[string]string{"a": "hash-a"}, nil); err != nil {
+		t.Fatalf("storeAllPackageFindings: %v", err)
+	}
+
+	_, ok := loadAllCachedFindings(dir, map[string]string{"a": "hash-a", "b": "hash-b-never-stored"})
+	if ok {
+		t.Fatal("loadAllCachedFindings reported a hit despite a missing package entry")
+	}
+}
+
+func sortFindings(fs []Finding) {
+	for i := 1; i < len(fs); i++ {
+		for j := i; j > 0 && fs[j-1].File+fs[j-1].Package > fs[j].File+fs[j].Package; j-- {
+			fs[j-1], fs[j] = fs[j], fs[j-1]
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// driverConfig describes a single SQL-accepting package as read from a
+// -config file. It mirrors sqlPackage but uses exported fields so it can be
+// unmarshalled from YAML or JSON.
+type driverConfig struct {
+	Package      string   `yaml:"package" json:"package"`
+	ParamNames   []string `yaml:"paramNames" json:"paramNames"`
+	IgnoredFiles []string `yaml:"ignoredFiles" json:"ignoredFiles"`
+}
+
+// fileConfig is the top-level shape of a -config file.
+type fileConfig struct {
+	Drivers  []driverConfig `yaml:"drivers" json:"drivers"`
+	Ignore   []string       `yaml:"ignore" json:"ignore"`
+	Builders []string       `yaml:"builders" json:"builders"`
+}
+
+// loadConfig reads and parses the descriptor at path. JSON and YAML are both
+// accepted; the format is chosen by the file extension, falling back to YAML
+// (which is a superset of JSON) when the extension is unrecognized.
+func loadConfig(path string) (*fileConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	var cfg fileConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config %s as JSON: %w", path, err)
+		}
+		return &cfg, nil
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s as YAML: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// applyConfig merges the drivers, ignore and builder-whitelist entries from
+// cfg into the built-in sqlPackages, ignoredFiles and builderWhitelist,
+// letting users extend safesql to cover additional database packages and
+// trusted query builders without recompiling.
+func applyConfig(cfg *fileConfig) {
+	for _, d := range cfg.Drivers {
+		sqlPackages = append(sqlPackages, sqlPackage{
+			packageName: d.Package,
+			paramNames:  d.ParamNames,
+		})
+		ignoredFiles = append(ignoredFiles, d.IgnoredFiles...)
+	}
+	ignoredFiles = append(ignoredFiles, cfg.Ignore...)
+
+	for _, b := range cfg.Builders {
+		if sel, ok := parseBuilderSelector(b); ok {
+			builderWhitelist = append(builderWhitelist, sel)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `
+drivers:
+  - package: example.com/mydb
+    paramNames: [q]
+    ignoredFiles: [mydb_test.go]
+ignore:
+  - legacy.go
+builders:
+  - example.com/mydb.Builder.Build
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	want := &fileConfig{
+		Drivers: []driverConfig{
+			{Package: "example.com/mydb", ParamNames: []string{"q"}, IgnoredFiles: []string{"mydb_test.go"}},
+		},
+		Ignore:   []string{"legacy.go"},
+		Builders: []string{"example.com/mydb.Builder.Build"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("loadConfig = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{
+		"drivers": [{"package": "example.com/mydb", "paramNames": ["q"]}],
+		"ignore": ["legacy.go"]
+	}`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if len(cfg.Drivers) != 1 || cfg.Drivers[0].Package != "example.com/mydb" {
+		t.Fatalf("loadConfig = %+v, want one driver for example.com/mydb", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Ignore, []string{"legacy.go"}) {
+		t.Fatalf("loadConfig.Ignore = %v, want [legacy.go]", cfg.Ignore)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("loadConfig on a missing file: got nil error, want one")
+	}
+}
+
+func TestApplyConfigMergesIntoGlobals(t *testing.T) {
+	origPackages := append([]sqlPackage{}, sqlPackages...)
+	origIgnored := append([]string{}, ignoredFiles...)
+	origWhitelist := append([]builderSelector{}, builderWhitelist...)
+	t.Cleanup(func() {
+		sqlPackages = origPackages
+		ignoredFiles = origIgnored
+		builderWhitelist = origWhitelist
+	})
+
+	applyConfig(&fileConfig{
+		Drivers: []driverConfig{
+			{Package: "example.com/mydb", ParamNames: []string{"q"}, IgnoredFiles: []string{"mydb_test.go"}},
+		},
+		Ignore:   []string{"legacy.go"},
+		Builders: []string{"example.com/mydb.Builder.Build"},
+	})
+
+	last := sqlPackages[len(sqlPackages)-1]
+	if last.packageName != "example.com/mydb" || !reflect.DeepEqual(last.paramNames, []string{"q"}) {
+		t.Fatalf("applyConfig did not append the configured driver, got %+v", last)
+	}
+
+	for _, want := range []string{"mydb_test.go", "legacy.go"} {
+		found := false
+		for _, f := range ignoredFiles {
+			if f == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("applyConfig did not merge ignored file %q, got %v", want, ignoredFiles)
+		}
+	}
+
+	lastSel := builderWhitelist[len(builderWhitelist)-1]
+	want := builderSelector{pkgPath: "example.com/mydb", typeName: "Builder", method: "Build"}
+	if lastSel != want {
+		t.Fatalf("applyConfig appended builder selector %+v, want %+v", lastSel, want)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
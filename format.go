@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+)
+
+// Finding is a single potentially unsafe SQL call, described in enough
+// detail to drive either the human-readable report or a machine-readable
+// one (JSON, SARIF).
+type Finding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Function   string `json:"function"`
+	Target     string `json:"target"`
+	ArgIndex   int    `json:"argIndex"`
+	Suppressed bool   `json:"suppressed"`
+
+	// Class and Severity are only populated in -taint mode; see taint.go.
+	Class    string `json:"class,omitempty"`
+	Severity string `json:"severity,omitempty"`
+
+	// Package is the import path of the SSA function the callsite was
+	// found in. It's only used to partition findings by package for the
+	// on-disk cache (see cache.go) and is never part of the rendered
+	// output.
+	Package string `json:"-"`
+}
+
+// BuildFindings turns the raw callsites reported by FindNonConstCalls, along
+// with the ignore-comment analysis from CheckIssues, into Findings.
+func BuildFindings(bad []BadCall, issues []Issue) []Finding {
+	suppressed := make(map[token.Position]bool, len(issues))
+	for _, issue := range issues {
+		suppressed[issue.statement] = issue.ignored
+	}
+
+	findings := make([]Finding, 0, len(bad))
+	for _, bc := range bad {
+		pos := bc.Site.Parent().Prog.Fset.Position(bc.Site.Pos())
+
+		function := ""
+		pkgPath := ""
+		if fn := bc.Site.Parent(); fn != nil {
+			function = fn.String()
+			if fn.Pkg != nil && fn.Pkg.Pkg != nil {
+				pkgPath = fn.Pkg.Pkg.Path()
+			}
+		}
+
+		findings = append(findings, Finding{
+			File:       pos.Filename,
+			Line:       pos.Line,
+			Column:     pos.Column,
+			Function:   function,
+			Target:     bc.Method.Func.FullName(),
+			ArgIndex:   bc.Method.Param,
+			Suppressed: suppressed[pos],
+			Package:    pkgPath,
+		})
+	}
+	return findings
+}
+
+// PrintText renders findings the same way safesql has always reported them:
+// one bullet point per finding.
+func PrintText(w io.Writer, findings []Finding) {
+	for _, f := range findings {
+		switch {
+		case f.Suppressed:
+			fmt.Fprintf(w, "- %s:%d:%d is potentially unsafe but file is ignored or statement ignored by comment\n", f.File, f.Line, f.Column)
+		case f.Severity != "":
+			fmt.Fprintf(w, "- %s:%d:%d: [%s/%s] potentially unsafe SQL statement in %s (argument %d of call to %s)\n", f.File, f.Line, f.Column, f.Severity, f.Class, f.Function, f.ArgIndex, f.Target)
+		default:
+			fmt.Fprintf(w, "- %s:%d:%d: potentially unsafe SQL statement in %s (argument %d of call to %s)\n", f.File, f.Line, f.Column, f.Function, f.ArgIndex, f.Target)
+		}
+	}
+}
+
+// PrintJSON renders findings as a JSON array, even when there are none.
+func PrintJSON(w io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPrintJSONEmptyFindingsIsEmptyArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintJSON(&buf, nil); err != nil {
+		t.Fatalf("PrintJSON: %v", err)
+	}
+
+	var decoded []Finding
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("PrintJSON on nil findings produced invalid JSON %q: %v", buf.String(), err)
+	}
+	if decoded == nil || len(decoded) != 0 {
+		t.Fatalf("PrintJSON(nil) decoded to %v, want []", decoded)
+	}
+}
+
+func TestPrintJSONOmitsPackage(t *testing.T) {
+	var buf bytes.Buffer
+	findings := []Finding{{File: "a.go", Line: 1, Function: "main.f", Package: "example.com/a"}}
+	if err := PrintJSON(&buf, findings); err != nil {
+		t.Fatalf("PrintJSON: %v", err)
+	}
+	if strings.Contains(buf.String(), "example.com/a") {
+		t.Fatalf("PrintJSON leaked the cache-internal Package field: %s", buf.String())
+	}
+}
+
+func TestPrintTextFormatsBySeverityAndSuppression(t *testing.T) {
+	var buf bytes.Buffer
+	PrintText(&buf, []Finding{
+		{File: "a.go", Line: 1, Column: 2, Function: "main.f", Target: "(*sql.DB).Query", ArgIndex: 0, Suppressed: true},
+		{File: "b.go", Line: 3, Column: 4, Function: "main.g", Target: "(*sql.DB).Exec", ArgIndex: 1, Class: "tainted", Severity: taintSeverityHigh},
+		{File: "c.go", Line: 5, Column: 6, Function: "main.h", Target: "(*sql.DB).Exec", ArgIndex: 0},
+	})
+
+	out := buf.String()
+	for _, want := range []string{"a.go:1:2", "ignored by comment", "b.go:3:4", "[high/tainted]", "c.go:5:6"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PrintText output missing %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -6,18 +6,15 @@ package main
 import (
 	"flag"
 	"fmt"
-	"go/build"
 	"go/token"
 	"go/types"
 	"io/ioutil"
 	"os"
 	"sort"
-
-	"path/filepath"
 	"strings"
 
 	"golang.org/x/tools/go/callgraph"
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/pointer"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
@@ -54,40 +51,72 @@ var ignoredFiles = []string{
 	"github.com/jackc/pgx/v4/pgxpool/conn.go",
 }
 
+// loaderMode is the go/packages mode required to build SSA for every
+// reachable package, including type information for dependencies.
+const loaderMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax |
+	packages.NeedTypesInfo | packages.NeedModule
+
 func main() {
-	var verbose, quiet bool
+	var verbose, quiet, taint bool
+	var tags, config, format, cache, cacheDir string
 	flag.BoolVar(&verbose, "v", false, "Verbose mode")
 	flag.BoolVar(&quiet, "q", false, "Only print on failure")
+	flag.StringVar(&tags, "tags", "", "Comma-separated list of build tags to apply, as in go build")
+	flag.StringVar(&config, "config", "", "Path to a YAML or JSON file describing additional SQL drivers to check")
+	flag.StringVar(&format, "format", "text", "Output format: text, json, or sarif")
+	flag.BoolVar(&taint, "taint", false, "Trace non-constant queries back to their source and classify them as constant, parameterized, or tainted")
+	flag.StringVar(&cache, "cache", "on", "Cache analysis results across runs, keyed by package source hashes; set to \"off\" to disable")
+	flag.StringVar(&cacheDir, "cache-dir", "", "Directory for the analysis cache (default $XDG_CACHE_HOME/safesql)")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-q] [-v] package1 [package2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-q] [-v] [-tags tag,list] [-config file] [-format text|json|sarif] [-taint] [-cache=off] [-cache-dir dir] package1 [package2 ...]\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
-	pkgs := flag.Args()
-	if len(pkgs) == 0 {
+	patterns := flag.Args()
+	if len(patterns) == 0 {
 		flag.Usage()
 		os.Exit(2)
 	}
+	switch format {
+	case "text", "json", "sarif":
+	default:
+		fmt.Printf("unknown -format %q: must be one of text, json, sarif\n", format)
+		os.Exit(2)
+	}
+
+	if config != "" {
+		cfg, err := loadConfig(config)
+		if err != nil {
+			fmt.Printf("error loading config %s: %v\n", config, err)
+			os.Exit(2)
+		}
+		applyConfig(cfg)
+	}
 
-	c := loader.Config{
-		FindPackage: FindPackage,
+	cfg := &packages.Config{
+		Mode: loaderMode,
 	}
-	for _, pkg := range pkgs {
-		c.Import(pkg)
+	if tags != "" {
+		cfg.BuildFlags = []string{"-tags", tags}
 	}
-	p, err := c.Load()
 
+	initial, err := packages.Load(cfg, patterns...)
 	if err != nil {
-		fmt.Printf("error loading packages %v: %v\n", pkgs, err)
+		fmt.Printf("error loading packages %v: %v\n", patterns, err)
+		os.Exit(2)
+	}
+	if packages.PrintErrors(initial) > 0 {
 		os.Exit(2)
 	}
 
-	imports := getImports(p)
+	allPkgs := allPackages(initial)
+	imports := getImports(allPkgs)
 	existOne := false
 	for i := range sqlPackages {
 		if _, exist := imports[sqlPackages[i].packageName]; exist {
-			if verbose {
+			if verbose && format == "text" {
 				fmt.Printf("Enabling support for %s\n", sqlPackages[i].packageName)
 			}
 			sqlPackages[i].enable = true
@@ -95,22 +124,54 @@ func main() {
 		}
 	}
 	if !existOne {
-		fmt.Printf("No packages in %v include a supported database driver", pkgs)
+		fmt.Printf("No packages in %v include a supported database driver", patterns)
 		os.Exit(2)
 	}
 
-	s := ssautil.CreateProgram(p, 0)
-	s.Build()
+	cacheEnabled := cache != "off"
+	var cacheDirResolved string
+	var pkgHashes map[string]string
+	if cacheEnabled {
+		cacheDirResolved = resolveCacheDir(cacheDir)
+		cfgDigest, err := configDigest(config)
+		if err != nil {
+			if verbose {
+				fmt.Printf("warning: could not hash -config file, skipping cache: %v\n", err)
+			}
+			cacheEnabled = false
+		} else if hashes, err := packageHashes(allPkgs, cfgDigest, tags, taint); err != nil {
+			if verbose {
+				fmt.Printf("warning: could not compute package hashes, skipping cache: %v\n", err)
+			}
+			cacheEnabled = false
+		} else {
+			pkgHashes = hashes
+			if findings, ok := loadAllCachedFindings(cacheDirResolved, pkgHashes); ok {
+				if verbose && format == "text" {
+					fmt.Println("using cached analysis results (every package hash matched)")
+				}
+				reportFindings(findings, format, taint, quiet)
+				return
+			}
+		}
+	}
+
+	prog, ssaPkgs := ssautil.AllPackages(initial, 0)
+	prog.Build()
 
 	qms := make([]*QueryMethod, 0)
 
 	for i := range sqlPackages {
 		if sqlPackages[i].enable {
-			qms = append(qms, FindQueryMethods(sqlPackages[i], p.Package(sqlPackages[i].packageName).Pkg, s)...)
+			sqlPkg, ok := allPkgs[sqlPackages[i].packageName]
+			if !ok {
+				continue
+			}
+			qms = append(qms, FindQueryMethods(sqlPackages[i], sqlPkg.Types, prog)...)
 		}
 	}
 
-	if verbose {
+	if verbose && format == "text" {
 		fmt.Println("database driver functions that accept queries:")
 		for _, m := range qms {
 			fmt.Printf("- %s (param %d)\n", m.Func, m.Param)
@@ -118,7 +179,7 @@ func main() {
 		fmt.Println()
 	}
 
-	mains := FindMains(p, s)
+	mains := FindMains(ssaPkgs)
 	if len(mains) == 0 {
 		fmt.Println("Did not find any commands (i.e., main functions).")
 		os.Exit(2)
@@ -135,20 +196,13 @@ func main() {
 
 	bad := FindNonConstCalls(res.CallGraph, qms)
 
-	if len(bad) == 0 {
-		if !quiet {
-			fmt.Println(`You're safe from SQL injection! Yay \o/`)
-		}
-		return
-	}
-
-	if verbose {
+	if verbose && format == "text" && len(bad) > 0 {
 		fmt.Printf("Found %d potentially unsafe SQL statements:\n", len(bad))
 	}
 
 	potentialBadStatements := []token.Position{}
-	for _, ci := range bad {
-		potentialBadStatements = append(potentialBadStatements, p.Fset.Position(ci.Pos()))
+	for _, bc := range bad {
+		potentialBadStatements = append(potentialBadStatements, prog.Fset.Position(bc.Site.Pos()))
 	}
 
 	issues, err := CheckIssues(potentialBadStatements)
@@ -157,23 +211,70 @@ func main() {
 		os.Exit(2)
 	}
 
-	if verbose {
+	findings := BuildFindings(bad, issues)
+
+	if taint {
+		findings = ApplyTaintAnalysis(bad, findings, res.CallGraph)
+	}
+
+	if verbose && format == "text" && len(findings) > 0 {
 		fmt.Println("Please ensure that all SQL queries you use are compile-time constants.")
 		fmt.Println("You should always use parameterized queries or prepared statements")
 		fmt.Println("instead of building queries from strings.")
 	}
 
-	hasNonIgnoredUnsafeStatement := false
+	if cacheEnabled {
+		if err := storeAllPackageFindings(cacheDirResolved, pkgHashes, findings); err != nil && verbose {
+			fmt.Printf("warning: could not write analysis cache: %v\n", err)
+		}
+	}
 
-	for _, issue := range issues {
-		if issue.ignored {
-			fmt.Printf("- %s is potentially unsafe but file is ignored or statement ignored by comment\n", issue.statement)
+	reportFindings(findings, format, taint, quiet)
+}
+
+// reportFindings prints findings in the requested format and exits with
+// status 1 if any non-suppressed, non-warning finding remains. Unlike text
+// mode, json and sarif always emit a (possibly empty) document regardless
+// of quiet, since CI tooling consuming those formats expects well-formed
+// output on every run.
+func reportFindings(findings []Finding, format string, taint, quiet bool) {
+	switch format {
+	case "json":
+		if err := PrintJSON(os.Stdout, findings); err != nil {
+			fmt.Printf("error formatting findings as JSON: %v\n", err)
+			os.Exit(2)
+		}
+	case "sarif":
+		if err := PrintSARIF(os.Stdout, findings); err != nil {
+			fmt.Printf("error formatting findings as SARIF: %v\n", err)
+			os.Exit(2)
+		}
+	default:
+		if len(findings) == 0 {
+			if !quiet {
+				fmt.Println(`You're safe from SQL injection! Yay \o/`)
+			}
 		} else {
-			fmt.Printf("- %s\n", issue.statement)
-			hasNonIgnoredUnsafeStatement = true
+			PrintText(os.Stdout, findings)
 		}
 	}
 
+	if len(findings) == 0 {
+		return
+	}
+
+	hasNonIgnoredUnsafeStatement := false
+	for _, f := range findings {
+		if f.Suppressed {
+			continue
+		}
+		if taint && f.Severity == taintSeverityWarning {
+			continue
+		}
+		hasNonIgnoredUnsafeStatement = true
+		break
+	}
+
 	if hasNonIgnoredUnsafeStatement {
 		os.Exit(1)
 	}
@@ -300,33 +401,50 @@ func FuncHasQuery(sqlPackages sqlPackage, s *types.Signature) (offset int, ok bo
 	return 0, false
 }
 
-// FindMains returns the set of all packages loaded into the given
-// loader.Program which contain main functions
-func FindMains(p *loader.Program, s *ssa.Program) []*ssa.Package {
-	ips := p.InitialPackages()
-	mains := make([]*ssa.Package, 0, len(ips))
-	for _, info := range ips {
-		ssaPkg := s.Package(info.Pkg)
-		if ssaPkg.Func("main") != nil {
+// FindMains returns the set of all SSA packages in the program which contain
+// main functions.
+func FindMains(ssaPkgs []*ssa.Package) []*ssa.Package {
+	mains := make([]*ssa.Package, 0, len(ssaPkgs))
+	for _, ssaPkg := range ssaPkgs {
+		if ssaPkg != nil && ssaPkg.Func("main") != nil {
 			mains = append(mains, ssaPkg)
 		}
 	}
 	return mains
 }
 
-func getImports(p *loader.Program) map[string]interface{} {
-	pkgs := make(map[string]interface{})
-	for _, pkg := range p.AllPackages {
-		if pkg.Importable {
-			pkgs[pkg.Pkg.Path()] = nil
-		}
+// allPackages walks the import graph rooted at the initial packages loaded
+// from the command line patterns and returns every reachable package
+// (including the initial ones), keyed by import path.
+func allPackages(initial []*packages.Package) map[string]*packages.Package {
+	all := make(map[string]*packages.Package)
+	packages.Visit(initial, func(pkg *packages.Package) bool {
+		all[pkg.PkgPath] = pkg
+		return true
+	}, nil)
+	return all
+}
+
+// getImports returns the set of import paths reachable from the initial
+// packages loaded from the command line patterns.
+func getImports(allPkgs map[string]*packages.Package) map[string]interface{} {
+	imports := make(map[string]interface{}, len(allPkgs))
+	for path := range allPkgs {
+		imports[path] = nil
 	}
-	return pkgs
+	return imports
+}
+
+// BadCall pairs a flagged callsite with the QueryMethod whose query
+// parameter was found not to be a compile-time constant there.
+type BadCall struct {
+	Site   ssa.CallInstruction
+	Method *QueryMethod
 }
 
 // FindNonConstCalls returns the set of callsites of the given set of methods
 // for which the "query" parameter is not a compile-time constant.
-func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstruction {
+func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []BadCall {
 	cg.DeleteSyntheticNodes()
 
 	// package database/sql has a couple helper functions which are thin
@@ -339,7 +457,7 @@ func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstru
 		okFuncs[m.SSA] = struct{}{}
 	}
 
-	bad := make([]ssa.CallInstruction, 0)
+	bad := make([]BadCall, 0)
 	for _, m := range qms {
 		node := cg.CreateNode(m.SSA)
 		for _, edge := range node.In {
@@ -375,53 +493,14 @@ func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstru
 					}
 				}
 
-				bad = append(bad, edge.Site)
-			}
-		}
-	}
-
-	return bad
-}
-
-// Deal with GO15VENDOREXPERIMENT
-func FindPackage(ctxt *build.Context, path, dir string, mode build.ImportMode) (*build.Package, error) {
-	if !useVendor {
-		return ctxt.Import(path, dir, mode)
-	}
+				if isWhitelistedBuilderCall(v) {
+					continue
+				}
 
-	// First, walk up the filesystem from dir looking for vendor directories
-	var vendorDir string
-	for tmp := dir; vendorDir == "" && tmp != "/"; tmp = filepath.Dir(tmp) {
-		dname := filepath.Join(tmp, "vendor", filepath.FromSlash(path))
-		fd, err := os.Open(dname)
-		if err != nil {
-			continue
-		}
-		// Directories are only valid if they contain at least one file
-		// with suffix ".go" (this also ensures that the file descriptor
-		// we have is in fact a directory)
-		names, err := fd.Readdirnames(-1)
-		if err != nil {
-			continue
-		}
-		for _, name := range names {
-			if strings.HasSuffix(name, ".go") {
-				vendorDir = filepath.ToSlash(dname)
-				break
+				bad = append(bad, BadCall{Site: edge.Site, Method: m})
 			}
 		}
 	}
 
-	if vendorDir != "" {
-		pkg, err := ctxt.ImportDir(vendorDir, mode)
-		if err != nil {
-			return nil, err
-		}
-		// Go tries to derive a valid import path for the package, but
-		// it's wrong (it includes "/vendor/"). Overwrite it here.
-		pkg.ImportPath = path
-		return pkg, nil
-	}
-
-	return ctxt.Import(path, dir, mode)
+	return bad
 }
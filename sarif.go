@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Minimal SARIF v2.1.0 structures — just enough to describe safesql's
+// findings so they can be uploaded to GitHub code scanning or any other
+// SARIF-consuming dashboard.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string    `json:"id"`
+	ShortDescription sarifText `json:"shortDescription"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	Level        string             `json:"level"`
+	Message      sarifText          `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind string `json:"kind"`
+}
+
+const sarifRuleID = "SQLInjection"
+
+// sarifLevel maps a Finding's suppression and -taint severity onto SARIF's
+// result.level vocabulary ("none", "note", "warning", "error").
+func sarifLevel(f Finding) string {
+	if f.Suppressed {
+		return "note"
+	}
+	switch f.Severity {
+	case taintSeverityWarning:
+		return "warning"
+	case taintSeverityHigh:
+		return "error"
+	default:
+		// -taint wasn't used: every non-suppressed finding is reported at
+		// the same severity as before.
+		return "error"
+	}
+}
+
+// PrintSARIF renders findings as a SARIF 2.1.0 log, suitable for GitHub code
+// scanning or any other SARIF-consuming dashboard.
+func PrintSARIF(w io.Writer, findings []Finding) error {
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "safesql",
+						InformationURI: "https://github.com/Intrinsec/safesql",
+						Rules: []sarifRule{
+							{
+								ID:               sarifRuleID,
+								ShortDescription: sarifText{Text: "Query argument is not a compile-time constant"},
+							},
+						},
+					},
+				},
+				Results: make([]sarifResult, 0, len(findings)),
+			},
+		},
+	}
+
+	run := &log.Runs[0]
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID: sarifRuleID,
+			Level:  sarifLevel(f),
+			Message: sarifText{
+				Text: fmt.Sprintf("argument %d of call to %s is not a compile-time constant", f.ArgIndex, f.Target),
+			},
+			Locations: []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: f.File},
+						Region: sarifRegion{
+							StartLine:   f.Line,
+							StartColumn: f.Column,
+						},
+					},
+				},
+			},
+		}
+		if f.Suppressed {
+			result.Suppressions = []sarifSuppression{{Kind: "inSource"}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
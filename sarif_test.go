@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSarifLevel(t *testing.T) {
+	cases := []struct {
+		name string
+		f    Finding
+		want string
+	}{
+		{"suppressed", Finding{Suppressed: true, Severity: taintSeverityHigh}, "note"},
+		{"taint warning", Finding{Severity: taintSeverityWarning}, "warning"},
+		{"taint high", Finding{Severity: taintSeverityHigh}, "error"},
+		{"no taint mode", Finding{}, "error"},
+	}
+	for _, c := range cases {
+		if got := sarifLevel(c.f); got != c.want {
+			t.Errorf("sarifLevel(%s) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPrintSARIFEmptyFindingsIsValidLog(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintSARIF(&buf, nil); err != nil {
+		t.Fatalf("PrintSARIF: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("PrintSARIF on nil findings produced invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(log.Runs) != 1 || log.Runs[0].Results == nil || len(log.Runs[0].Results) != 0 {
+		t.Fatalf("PrintSARIF(nil) = %+v, want one run with an empty (non-null) results array", log)
+	}
+}
+
+func TestPrintSARIFReflectsTaintSeverity(t *testing.T) {
+	var buf bytes.Buffer
+	err := PrintSARIF(&buf, []Finding{
+		{File: "a.go", Line: 1, Class: string(TaintParameterized), Severity: taintSeverityWarning},
+		{File: "b.go", Line: 2, Class: string(TaintUnsafe), Severity: taintSeverityHigh},
+		{File: "c.go", Line: 3, Suppressed: true, Severity: taintSeverityHigh},
+	})
+	if err != nil {
+		t.Fatalf("PrintSARIF: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	wantLevels := []string{"warning", "error", "note"}
+	for i, want := range wantLevels {
+		if results[i].Level != want {
+			t.Errorf("result[%d].Level = %q, want %q", i, results[i].Level, want)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// TaintClass is the outcome of tracing a non-constant query argument back
+// towards its source.
+type TaintClass string
+
+const (
+	// TaintConstant means the value is a compile-time constant once
+	// concatenation, Phi nodes and known helper calls are unwound; this is
+	// the false positive that -taint lets us suppress.
+	TaintConstant TaintClass = "constant"
+	// TaintParameterized means the value is built from a constant format
+	// string (or similar) combined with opaque, but not obviously
+	// dangerous, values. It's worth a warning but not a hard failure.
+	TaintParameterized TaintClass = "parameterized"
+	// TaintUnsafe means the value is traceable to an http.Request, os.Args,
+	// a flag, os.Getenv, or a parameter of an exported function.
+	TaintUnsafe TaintClass = "tainted"
+
+	taintSeverityHigh    = "high"
+	taintSeverityWarning = "warning"
+)
+
+// ApplyTaintAnalysis classifies each bad call's query argument and drops the
+// ones that turn out to be fully constant, since those are false positives
+// from FindNonConstCalls's shallow *ssa.Const check. bad and findings must
+// be parallel slices, as returned by FindNonConstCalls and BuildFindings. cg
+// is the pointer-analysis callgraph, used to resolve the possible callees of
+// dynamically-dispatched (interface method) calls encountered while tracing
+// a query back to its source; it may be nil, in which case such calls are
+// classified conservatively instead of resolved.
+func ApplyTaintAnalysis(bad []BadCall, findings []Finding, cg *callgraph.Graph) []Finding {
+	out := make([]Finding, 0, len(findings))
+	for i, bc := range bad {
+		class := ClassifyQuery(queryArgValue(bc), cg)
+		if class == TaintConstant {
+			continue
+		}
+
+		f := findings[i]
+		f.Class = string(class)
+		if class == TaintUnsafe {
+			f.Severity = taintSeverityHigh
+		} else {
+			f.Severity = taintSeverityWarning
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// queryArgValue returns the SSA value passed as the query parameter at bc's
+// callsite, accounting for the receiver occasionally being the first
+// argument (mirrors the offset logic in FindNonConstCalls).
+func queryArgValue(bc BadCall) ssa.Value {
+	args := bc.Site.Common().Args
+	if len(args) == bc.Method.ArgCount+1 {
+		args = args[1:]
+	}
+	return args[bc.Method.Param]
+}
+
+// taintTracer carries the recursion state shared across a single
+// ClassifyQuery call: seen guards against revisiting a value already on the
+// current def-use chain (e.g. a loop-carried Phi), seenFuncs guards against
+// re-entering a function already being traced through (recursive or mutually
+// recursive helpers), and cg is the pointer-analysis callgraph used to
+// resolve interface-dispatched calls.
+type taintTracer struct {
+	seen      map[ssa.Value]bool
+	seenFuncs map[*ssa.Function]bool
+	cg        *callgraph.Graph
+}
+
+// ClassifyQuery walks the SSA def-use chain backward from v, through string
+// concatenation, Phi nodes, known helper calls (fmt.Sprintf and friends),
+// and calls to other functions in the program (following static calls
+// directly into the callee's body, and dynamic/interface calls via cg), to
+// classify it as constant, parameterized, or tainted. cg may be nil.
+func ClassifyQuery(v ssa.Value, cg *callgraph.Graph) TaintClass {
+	t := &taintTracer{
+		seen:      make(map[ssa.Value]bool),
+		seenFuncs: make(map[*ssa.Function]bool),
+		cg:        cg,
+	}
+	return t.classifyValue(v)
+}
+
+func (t *taintTracer) classifyValue(v ssa.Value) TaintClass {
+	if v == nil {
+		return TaintUnsafe
+	}
+	if t.seen[v] {
+		// Already on the stack (e.g. a loop-carried Phi); don't let it
+		// drag the whole chain down by itself.
+		return TaintConstant
+	}
+	t.seen[v] = true
+
+	switch val := v.(type) {
+	case *ssa.Const:
+		return TaintConstant
+
+	case *ssa.Parameter:
+		if isTaintedParameter(val) {
+			return TaintUnsafe
+		}
+		return TaintParameterized
+
+	case *ssa.Global:
+		if isOSArgs(val) {
+			return TaintUnsafe
+		}
+		return TaintParameterized
+
+	case *ssa.BinOp:
+		if val.Op == token.ADD {
+			return worstOf(t.classifyValue(val.X), t.classifyValue(val.Y))
+		}
+		return TaintUnsafe
+
+	case *ssa.Phi:
+		class := TaintConstant
+		for _, edge := range val.Edges {
+			class = worstOf(class, t.classifyValue(edge))
+		}
+		return class
+
+	case *ssa.Extract:
+		return t.classifyValue(val.Tuple)
+
+	case *ssa.MakeInterface:
+		return t.classifyValue(val.X)
+
+	case *ssa.ChangeType:
+		return t.classifyValue(val.X)
+
+	case *ssa.Convert:
+		return t.classifyValue(val.X)
+
+	case *ssa.UnOp:
+		return t.classifyValue(val.X)
+
+	case *ssa.Call:
+		return t.classifyCall(val)
+
+	default:
+		// Something we don't specifically unwind (e.g. a field load off an
+		// arbitrary struct): be conservative.
+		return TaintUnsafe
+	}
+}
+
+func worstOf(a, b TaintClass) TaintClass {
+	rank := map[TaintClass]int{TaintConstant: 0, TaintParameterized: 1, TaintUnsafe: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// classifyCall handles the common helpers used to build query strings:
+// fmt.Sprintf (and its siblings) are treated as "parameterized" as long as
+// the format string itself is constant and none of the substituted
+// arguments trace to a known taint source. Known taint-source APIs are
+// recognized directly. Anything else is a call to code this program
+// controls (statically resolved) or might resolve to at runtime
+// (interface-dispatched, resolved via cg): rather than assume it's safe,
+// trace into the callee(s)' own return value(s).
+func (t *taintTracer) classifyCall(call *ssa.Call) TaintClass {
+	callee := call.Call.StaticCallee()
+	if callee == nil {
+		if isHTTPRequestCall(call) {
+			return TaintUnsafe
+		}
+		if callees := t.resolveDynamicCallees(call); len(callees) > 0 {
+			class := TaintConstant
+			for _, c := range callees {
+				class = worstOf(class, t.classifyCallee(c))
+			}
+			return class
+		}
+		// No static callee and the callgraph couldn't (or wasn't asked to)
+		// resolve it: be conservative, but don't fail the build over it.
+		return TaintParameterized
+	}
+
+	if isTaintSourceFunc(callee) {
+		return TaintUnsafe
+	}
+
+	switch funcPath(callee) {
+	case "fmt.Sprintf", "fmt.Sprint", "fmt.Sprintln":
+		args := call.Call.Args
+		if len(args) == 0 {
+			return TaintUnsafe
+		}
+		class := t.classifyValue(args[0])
+		for _, a := range args[1:] {
+			if t.classifyValue(a) == TaintUnsafe {
+				return TaintUnsafe
+			}
+		}
+		if class == TaintConstant {
+			return TaintParameterized
+		}
+		return class
+	case "strings.Join":
+		return TaintParameterized
+	}
+
+	return t.classifyCallee(callee)
+}
+
+// resolveDynamicCallees returns the functions the pointer-analysis callgraph
+// says call might dispatch to at runtime (e.g. an interface method call),
+// or nil if cg is unavailable or has no edges for this call site.
+func (t *taintTracer) resolveDynamicCallees(call *ssa.Call) []*ssa.Function {
+	if t.cg == nil {
+		return nil
+	}
+	node := t.cg.Nodes[call.Parent()]
+	if node == nil {
+		return nil
+	}
+	var callees []*ssa.Function
+	for _, edge := range node.Out {
+		if edge.Site == ssa.CallInstruction(call) && edge.Callee != nil && edge.Callee.Func != nil {
+			callees = append(callees, edge.Callee.Func)
+		}
+	}
+	return callees
+}
+
+// classifyCallee classifies a resolved callee (static or dynamic) by
+// recognizing known taint-source APIs, then falling back to tracing into
+// its own return value(s).
+func (t *taintTracer) classifyCallee(fn *ssa.Function) TaintClass {
+	if isTaintSourceFunc(fn) {
+		return TaintUnsafe
+	}
+	return t.classifyFunctionReturns(fn)
+}
+
+// classifyFunctionReturns traces into fn's own body and classifies the
+// worst of its return value(s), the same way classifyValue traces a Phi's
+// incoming edges. Functions without a body (assembly stubs, cgo, or
+// anything else the loader couldn't give us SSA for) are classified as
+// TaintParameterized, matching the previous conservative default for calls
+// this analysis can't see into.
+func (t *taintTracer) classifyFunctionReturns(fn *ssa.Function) TaintClass {
+	if fn == nil || fn.Blocks == nil {
+		return TaintParameterized
+	}
+	if t.seenFuncs[fn] {
+		// Already being traced through (recursion); don't let it drag the
+		// rest of the chain down by itself.
+		return TaintConstant
+	}
+	t.seenFuncs[fn] = true
+
+	class := TaintConstant
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			ret, ok := instr.(*ssa.Return)
+			if !ok || len(ret.Results) == 0 {
+				continue
+			}
+			class = worstOf(class, t.classifyValue(ret.Results[0]))
+		}
+	}
+	return class
+}
+
+func funcPath(fn *ssa.Function) string {
+	if fn.Pkg == nil || fn.Pkg.Pkg == nil {
+		return fn.Name()
+	}
+	return fn.Pkg.Pkg.Path() + "." + fn.Name()
+}
+
+// isTaintedParameter reports whether p should be treated as attacker
+// controlled: either it is a parameter of an exported function (the program
+// under analysis can't see who calls it or with what), or its type is
+// *net/http.Request.
+func isTaintedParameter(p *ssa.Parameter) bool {
+	if fn := p.Parent(); fn != nil {
+		if obj := fn.Object(); obj != nil && obj.Exported() {
+			return true
+		}
+	}
+	return isHTTPRequestType(p.Type())
+}
+
+func isOSArgs(g *ssa.Global) bool {
+	return g.Pkg != nil && g.Pkg.Pkg.Path() == "os" && g.Name() == "Args"
+}
+
+func isTaintSourceFunc(fn *ssa.Function) bool {
+	if fn.Pkg == nil || fn.Pkg.Pkg == nil {
+		return false
+	}
+	switch fn.Pkg.Pkg.Path() {
+	case "os":
+		return fn.Name() == "Getenv" || fn.Name() == "LookupEnv"
+	case "flag":
+		return true
+	}
+	return isHTTPRequestMethod(fn)
+}
+
+func isHTTPRequestCall(call *ssa.Call) bool {
+	recv := call.Call.Value
+	if recv == nil {
+		return false
+	}
+	return isHTTPRequestType(recv.Type())
+}
+
+func isHTTPRequestMethod(fn *ssa.Function) bool {
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return false
+	}
+	return isHTTPRequestType(recv.Type())
+}
+
+// isHTTPRequestType reports whether t is (a pointer to) net/http.Request.
+func isHTTPRequestType(t types.Type) bool {
+	for {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+			continue
+		}
+		break
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "Request"
+}
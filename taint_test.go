@@ -0,0 +1,147 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// buildSSAFunc type-checks and SSA-builds src (a single-file package named
+// "p") and returns the *ssa.Function for the top-level func named name, with
+// its body already built.
+func buildSSAFunc(t *testing.T, src, name string) *ssa.Function {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("parsing source: %v", err)
+	}
+
+	pkg := types.NewPackage("p", "")
+	ssaPkg, _, err := ssautil.BuildPackage(
+		&types.Config{Importer: importer.Default()},
+		fset, pkg, []*ast.File{f}, ssa.SanityCheckFunctions)
+	if err != nil {
+		t.Fatalf("building SSA: %v", err)
+	}
+
+	fn := ssaPkg.Func(name)
+	if fn == nil {
+		t.Fatalf("no function %q in built package", name)
+	}
+	return fn
+}
+
+// returnValue returns the value a single-block function hands back via its
+// sole return statement, i.e. the value ClassifyQuery would be asked to
+// trace for a query built and returned by that function.
+func returnValue(t *testing.T, fn *ssa.Function) ssa.Value {
+	t.Helper()
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if ret, ok := instr.(*ssa.Return); ok {
+				if len(ret.Results) != 1 {
+					t.Fatalf("function %s returns %d values, want 1", fn.Name(), len(ret.Results))
+				}
+				return ret.Results[0]
+			}
+		}
+	}
+	t.Fatalf("function %s has no return instruction", fn.Name())
+	return nil
+}
+
+func TestClassifyQueryConstant(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+func f() string { return "SELECT 1" }
+`, "f")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintConstant {
+		t.Errorf("ClassifyQuery(constant) = %s, want %s", got, TaintConstant)
+	}
+}
+
+func TestClassifyQueryParameterizedFromUnexportedParam(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+func f(x string) string { return x }
+`, "f")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintParameterized {
+		t.Errorf("ClassifyQuery(unexported param) = %s, want %s", got, TaintParameterized)
+	}
+}
+
+func TestClassifyQueryTaintedFromExportedParam(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+func F(x string) string { return x }
+`, "F")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintUnsafe {
+		t.Errorf("ClassifyQuery(exported func param) = %s, want %s", got, TaintUnsafe)
+	}
+}
+
+func TestClassifyQueryConcatWorstOfOperands(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+func f(x string) string { return "SELECT " + x }
+`, "f")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintParameterized {
+		t.Errorf("ClassifyQuery(const + unexported param) = %s, want %s", got, TaintParameterized)
+	}
+}
+
+func TestClassifyQuerySprintfWithNoVariadicArgs(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+import "fmt"
+
+func f() string { return fmt.Sprintf("SELECT 1") }
+`, "f")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintParameterized {
+		t.Errorf("ClassifyQuery(Sprintf with a constant format and no args) = %s, want %s", got, TaintParameterized)
+	}
+}
+
+func TestClassifyQuerySprintfWithTaintedArg(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+import "fmt"
+
+func F(x string) string { return fmt.Sprintf("SELECT %s", x) }
+`, "F")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintUnsafe {
+		t.Errorf("ClassifyQuery(Sprintf fed by a tainted arg) = %s, want %s", got, TaintUnsafe)
+	}
+}
+
+func TestClassifyQueryRecursesIntoLocalHelper(t *testing.T) {
+	// A one-line indirection through a local helper must not downgrade a
+	// genuine taint source to "parameterized": the query is still a
+	// verbatim, unsanitized env var once getQuery is traced into.
+	fn := buildSSAFunc(t, `package p
+
+import "os"
+
+func getQuery() string { return os.Getenv("SAFESQL_QUERY") }
+
+func f() string { return getQuery() }
+`, "f")
+
+	if got := ClassifyQuery(returnValue(t, fn), nil); got != TaintUnsafe {
+		t.Errorf("ClassifyQuery(one layer of indirection to os.Getenv) = %s, want %s", got, TaintUnsafe)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// builderSelector identifies a method, resolved as "pkgpath.Type.Method",
+// whose return value should be trusted as a safe SQL query even though it
+// isn't a compile-time constant — e.g. the ToSql()/ToSQL() methods of
+// popular query builders.
+type builderSelector struct {
+	pkgPath  string
+	typeName string
+	method   string
+}
+
+// builderWhitelist is the active set of trusted builder selectors: the
+// built-in defaults plus anything merged in from a -config file.
+var builderWhitelist = append([]builderSelector{}, defaultBuilderWhitelist...)
+
+var defaultBuilderWhitelist = []builderSelector{
+	{pkgPath: "github.com/Masterminds/squirrel", typeName: "SelectBuilder", method: "ToSql"},
+	{pkgPath: "github.com/Masterminds/squirrel", typeName: "InsertBuilder", method: "ToSql"},
+	{pkgPath: "github.com/Masterminds/squirrel", typeName: "UpdateBuilder", method: "ToSql"},
+	{pkgPath: "github.com/Masterminds/squirrel", typeName: "DeleteBuilder", method: "ToSql"},
+	{pkgPath: "github.com/doug-martin/goqu/v9", typeName: "SelectDataset", method: "ToSQL"},
+	{pkgPath: "github.com/doug-martin/goqu/v9", typeName: "InsertDataset", method: "ToSQL"},
+	{pkgPath: "github.com/doug-martin/goqu/v9", typeName: "UpdateDataset", method: "ToSQL"},
+	{pkgPath: "github.com/doug-martin/goqu/v9", typeName: "DeleteDataset", method: "ToSQL"},
+	{pkgPath: "github.com/lann/builder", typeName: "Builder", method: "ToSql"},
+}
+
+// parseBuilderSelector parses the "pkgpath.Type.Method" form used in
+// -config files, e.g. "github.com/Masterminds/squirrel.SelectBuilder.ToSql".
+// The package path itself may contain dots (as in "github.com"), so the
+// last two dot-separated components are always taken as the type and
+// method names.
+func parseBuilderSelector(s string) (builderSelector, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 3 {
+		return builderSelector{}, false
+	}
+	n := len(parts)
+	return builderSelector{
+		pkgPath:  strings.Join(parts[:n-2], "."),
+		typeName: parts[n-2],
+		method:   parts[n-1],
+	}, true
+}
+
+// isWhitelistedBuilderCall reports whether v is (derived from) a call to a
+// method in builderWhitelist, in which case it should be trusted as a safe
+// query even though it isn't a compile-time constant.
+func isWhitelistedBuilderCall(v ssa.Value) bool {
+	call := builderCallFor(v)
+	if call == nil {
+		return false
+	}
+	callee := call.Call.StaticCallee()
+	if callee == nil {
+		return false
+	}
+	return matchesBuilderWhitelist(callee)
+}
+
+// builderCallFor unwraps the usual conversions between a call and the
+// query-argument value that ends up at the callsite (tuple extraction,
+// interface boxing, type conversions) to find the underlying *ssa.Call, if
+// any.
+func builderCallFor(v ssa.Value) *ssa.Call {
+	switch val := v.(type) {
+	case *ssa.Call:
+		return val
+	case *ssa.Extract:
+		return builderCallFor(val.Tuple)
+	case *ssa.MakeInterface:
+		return builderCallFor(val.X)
+	case *ssa.ChangeType:
+		return builderCallFor(val.X)
+	case *ssa.Convert:
+		return builderCallFor(val.X)
+	case *ssa.UnOp:
+		return builderCallFor(val.X)
+	default:
+		return nil
+	}
+}
+
+func matchesBuilderWhitelist(fn *ssa.Function) bool {
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return false
+	}
+	typeName, pkgPath := recvTypeAndPkg(recv.Type())
+	if typeName == "" {
+		return false
+	}
+	for _, sel := range builderWhitelist {
+		if sel.pkgPath == pkgPath && sel.typeName == typeName && sel.method == fn.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+func recvTypeAndPkg(t types.Type) (name, pkgPath string) {
+	for {
+		ptr, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return "", ""
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return obj.Name(), ""
+	}
+	return obj.Name(), obj.Pkg().Path()
+}
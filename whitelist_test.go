@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// calledMethod returns the *ssa.Function for the method invoked by fn's sole
+// return statement, e.g. `return b.ToSQL()`. Methods aren't reachable via
+// (*ssa.Package).Func, so tests that need one build a small call site and
+// pull the callee off the resulting *ssa.Call instead.
+func calledMethod(t *testing.T, fn *ssa.Function) *ssa.Function {
+	t.Helper()
+	call, ok := returnValue(t, fn).(*ssa.Call)
+	if !ok {
+		t.Fatalf("function %s does not return a direct call", fn.Name())
+	}
+	callee := call.Call.StaticCallee()
+	if callee == nil {
+		t.Fatalf("function %s's call has no static callee", fn.Name())
+	}
+	return callee
+}
+
+func TestParseBuilderSelector(t *testing.T) {
+	cases := []struct {
+		in   string
+		want builderSelector
+		ok   bool
+	}{
+		{
+			in:   "github.com/Masterminds/squirrel.SelectBuilder.ToSql",
+			want: builderSelector{pkgPath: "github.com/Masterminds/squirrel", typeName: "SelectBuilder", method: "ToSql"},
+			ok:   true,
+		},
+		{
+			in:   "example.com/a/b/c.Builder.Build",
+			want: builderSelector{pkgPath: "example.com/a/b/c", typeName: "Builder", method: "Build"},
+			ok:   true,
+		},
+		{in: "Builder.Build", ok: false},
+		{in: "Build", ok: false},
+		{in: "", ok: false},
+	}
+	for _, c := range cases {
+		got, ok := parseBuilderSelector(c.in)
+		if ok != c.ok {
+			t.Errorf("parseBuilderSelector(%q) ok = %v, want %v", c.in, ok, c.ok)
+			continue
+		}
+		if ok && got != c.want {
+			t.Errorf("parseBuilderSelector(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMatchesBuilderWhitelist(t *testing.T) {
+	origWhitelist := builderWhitelist
+	builderWhitelist = []builderSelector{
+		{pkgPath: "p", typeName: "Builder", method: "ToSQL"},
+	}
+	t.Cleanup(func() { builderWhitelist = origWhitelist })
+
+	useToSQL := buildSSAFunc(t, `package p
+
+type Builder struct{}
+
+func (b *Builder) ToSQL() string { return "" }
+func (b *Builder) Other() string { return "" }
+
+func UseToSQL(b *Builder) string { return b.ToSQL() }
+`, "UseToSQL")
+	if !matchesBuilderWhitelist(calledMethod(t, useToSQL)) {
+		t.Error("matchesBuilderWhitelist(Builder.ToSQL) = false, want true")
+	}
+
+	useOther := buildSSAFunc(t, `package p
+
+type Builder struct{}
+
+func (b *Builder) ToSQL() string { return "" }
+func (b *Builder) Other() string { return "" }
+
+func UseOther(b *Builder) string { return b.Other() }
+`, "UseOther")
+	if matchesBuilderWhitelist(calledMethod(t, useOther)) {
+		t.Error("matchesBuilderWhitelist(Builder.Other) = true, want false")
+	}
+
+	plain := buildSSAFunc(t, `package p
+
+func Plain() string { return "" }
+`, "Plain")
+	if matchesBuilderWhitelist(plain) {
+		t.Error("matchesBuilderWhitelist(func with no receiver) = true, want false")
+	}
+}
+
+func TestIsWhitelistedBuilderCall(t *testing.T) {
+	origWhitelist := builderWhitelist
+	builderWhitelist = []builderSelector{
+		{pkgPath: "p", typeName: "Builder", method: "ToSQL"},
+	}
+	t.Cleanup(func() { builderWhitelist = origWhitelist })
+
+	fn := buildSSAFunc(t, `package p
+
+type Builder struct{}
+
+func (b *Builder) ToSQL() string { return "" }
+
+func Use(b *Builder) string { return b.ToSQL() }
+`, "Use")
+
+	ret := returnValue(t, fn)
+	if !isWhitelistedBuilderCall(ret) {
+		t.Error("isWhitelistedBuilderCall(b.ToSQL()) = false, want true")
+	}
+}
+
+func TestBuilderCallForUnwrapsConversions(t *testing.T) {
+	fn := buildSSAFunc(t, `package p
+
+func inner() string { return "x" }
+
+func f() interface{} { return inner() }
+`, "f")
+
+	ret := returnValue(t, fn)
+	if call := builderCallFor(ret); call == nil {
+		t.Fatal("builderCallFor did not unwrap the MakeInterface to find the underlying call")
+	} else if call.Call.StaticCallee().Name() != "inner" {
+		t.Errorf("builderCallFor found call to %s, want inner", call.Call.StaticCallee().Name())
+	}
+}